@@ -0,0 +1,102 @@
+// Copyright 2019 Yoshi Yamaguchi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+
+	"gopkg.in/yaml.v2"
+)
+
+// LocationConfig describes one place susanoo should poll weather for.
+type LocationConfig struct {
+	Name      string  `yaml:"name"`
+	Latitude  float64 `yaml:"latitude"`
+	Longitude float64 `yaml:"longitude"`
+
+	// OWMCityID is an optional OpenWeatherMap city ID, preferred over
+	// Latitude/Longitude by providers that support it.
+	OWMCityID int `yaml:"owm_city_id,omitempty"`
+}
+
+// Coordinate returns loc in the form WeatherProvider.Fetch expects.
+func (loc LocationConfig) Coordinate() *Coordinate {
+	return &Coordinate{
+		Latitude:  loc.Latitude,
+		Longitude: loc.Longitude,
+		OWMCityID: loc.OWMCityID,
+	}
+}
+
+// ProviderConfig holds the credentials and polling cadence for a single
+// built-in WeatherProvider. A zero *PollIntervalSeconds falls back to
+// the provider's own default.
+type ProviderConfig struct {
+	OWMAPIKey              string `yaml:"owm_api_key"`
+	OWMPollIntervalSeconds int    `yaml:"owm_poll_interval_seconds,omitempty"`
+
+	// DarkSkyAPIKey and DarkSkyPollIntervalSeconds only take effect when
+	// susanoo is built with -tags deprecated; DarkSky shut down in 2023.
+	DarkSkyAPIKey              string `yaml:"darksky_api_key,omitempty"`
+	DarkSkyPollIntervalSeconds int    `yaml:"darksky_poll_interval_seconds,omitempty"`
+
+	OpenMeteoPollIntervalSeconds int `yaml:"open_meteo_poll_interval_seconds,omitempty"`
+
+	// MetNoUserAgent identifies this deployment to the Met.no API, which
+	// requires an identifiable User-Agent. It should include operator
+	// contact details; DefaultMetNoUserAgent is used when empty.
+	MetNoUserAgent           string `yaml:"metno_user_agent,omitempty"`
+	MetNoPollIntervalSeconds int    `yaml:"metno_poll_interval_seconds,omitempty"`
+}
+
+// Config is the top-level shape of the susanoo config file, loaded once
+// at startup by LoadConfig and again on every SIGHUP.
+type Config struct {
+	// Language is the ISO 639-1 language code providers should request
+	// weather descriptions in, e.g. "en" or "ja".
+	Language string `yaml:"language"`
+
+	Providers ProviderConfig   `yaml:"providers"`
+	Locations []LocationConfig `yaml:"locations"`
+
+	// LocalStorePath is where the SQLite sink persists readings for
+	// offline use and replay. DefaultLocalStorePath is used when empty.
+	LocalStorePath string `yaml:"local_store_path,omitempty"`
+}
+
+// DefaultLocalStorePath is the SQLite database path used when Config.LocalStorePath is empty.
+const DefaultLocalStorePath = "susanoo.db"
+
+// StorePath returns cfg.LocalStorePath, falling back to DefaultLocalStorePath.
+func (cfg *Config) StorePath() string {
+	if cfg.LocalStorePath == "" {
+		return DefaultLocalStorePath
+	}
+	return cfg.LocalStorePath
+}
+
+// LoadConfig reads and parses the YAML config file at filename.
+func LoadConfig(filename string) (*Config, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(b, &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
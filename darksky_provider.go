@@ -0,0 +1,167 @@
+// Copyright 2019 Yoshi Yamaguchi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// DarkSky shut down on 2023-03-31. This provider is kept only for
+// deployments pinned to an old config during migration to Open-Meteo or
+// Met.no; build with -tags deprecated to include it.
+
+//go:build deprecated
+// +build deprecated
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	// DarkSkyForecastAPIURL is the DarkSky forecast endpoint. minutely
+	// and alerts are excluded since susanoo doesn't use them; hourly and
+	// daily are kept so Forecast can report a forecast horizon.
+	// https://darksky.net/dev/docs#forecast-request
+	DarkSkyForecastAPIURL = "https://api.darksky.net/forecast/%s/%f,%f?exclude=minutely,alerts&lang=en&units=si"
+
+	// DarkSkyPollInterval is the interval to fetch data from DarkSky.
+	// DarkSky has limit of 1000 call per day for free tier.
+	// https://darksky.net/dev/docs/faq#cost
+	DarkSkyPollInterval = 90 * time.Second
+)
+
+// DarkSkyProvider implements WeatherProvider on top of the DarkSky
+// forecast API.
+type DarkSkyProvider struct {
+	apiKey       string
+	pollInterval time.Duration
+}
+
+// NewDarkSkyProvider returns a DarkSkyProvider that authenticates with
+// apiKey. pollInterval overrides DarkSkyPollInterval when positive.
+func NewDarkSkyProvider(apiKey string, pollInterval time.Duration) *DarkSkyProvider {
+	if pollInterval <= 0 {
+		pollInterval = DarkSkyPollInterval
+	}
+	return &DarkSkyProvider{apiKey: apiKey, pollInterval: pollInterval}
+}
+
+func (p *DarkSkyProvider) Name() string {
+	return "darksky"
+}
+
+func (p *DarkSkyProvider) MinPollInterval() time.Duration {
+	return p.pollInterval
+}
+
+func (p *DarkSkyProvider) Fetch(ctx context.Context, coord *Coordinate) (*Weather, error) {
+	f, err := CallDarkSkyForecast(p.apiKey, coord)
+	if err != nil {
+		return nil, err
+	}
+	return DSToWeather(f), nil
+}
+
+// Forecast implements ForecastProvider using the hourly and daily blocks
+// of the same DarkSky forecast response Fetch uses for current
+// conditions.
+func (p *DarkSkyProvider) Forecast(ctx context.Context, coord *Coordinate) ([]ForecastPoint, error) {
+	f, err := CallDarkSkyForecast(p.apiKey, coord)
+	if err != nil {
+		return nil, err
+	}
+
+	points := make([]ForecastPoint, 0, len(f.Hourly.Data)+len(f.Daily.Data))
+	for _, h := range f.Hourly.Data {
+		points = append(points, ForecastPoint{
+			OffsetHours:       float64(h.Time-f.Currently.Time) / 3600,
+			Temperature:       h.Temperature,
+			PrecipProbability: h.PrecipProbability * 100,
+		})
+	}
+	for _, d := range f.Daily.Data {
+		points = append(points, ForecastPoint{
+			OffsetHours:       float64(d.Time-f.Currently.Time) / 3600,
+			Temperature:       d.TemperatureHigh,
+			PrecipProbability: d.PrecipProbability * 100,
+		})
+	}
+	return points, nil
+}
+
+type DarkSkyForecast struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	TimeZone  string  `json:"timezone"`
+	Currently struct {
+		Time            int64   `json:"time"`
+		Summary         string  `json:"summary"`
+		Icon            string  `json:"icon"`
+		Temperature     float64 `json:"temperature"`
+		Pressure        float64 `json:"pressure"`
+		Humidity        float64 `json:"humidity"`
+		WindSpeed       float64 `json:"windSpeed"`
+		WindBearing     int     `json:"windBearing"`
+		PrecipIntensity float64 `json:"precipIntensity"`
+		CloudCover      float64 `json:"cloudCover"`
+		UVIndex         float64 `json:"uvIndex"`
+	} `json:"currently"`
+	Hourly struct {
+		Data []struct {
+			Time              int64   `json:"time"`
+			Temperature       float64 `json:"temperature"`
+			PrecipProbability float64 `json:"precipProbability"`
+		} `json:"data"`
+	} `json:"hourly"`
+	Daily struct {
+		Data []struct {
+			Time              int64   `json:"time"`
+			TemperatureHigh   float64 `json:"temperatureHigh"`
+			PrecipProbability float64 `json:"precipProbability"`
+		} `json:"data"`
+	} `json:"daily"`
+}
+
+func CallDarkSkyForecast(apiKey string, coord *Coordinate) (*DarkSkyForecast, error) {
+	resp, err := http.Get(
+		fmt.Sprintf(DarkSkyForecastAPIURL, apiKey, coord.Latitude, coord.Longitude))
+	if err != nil {
+		logger.Errorf("failed to call DarkSky forecast API: %s", err)
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	var f DarkSkyForecast
+	err = decoder.Decode(&f)
+	if err != nil {
+		logger.Errorf("failed to decode DarkSky reponse: %s", err)
+		return nil, err
+	}
+	return &f, nil
+}
+
+func DSToWeather(f *DarkSkyForecast) *Weather {
+	return &Weather{
+		Temperature: f.Currently.Temperature,
+		Pressure:    f.Currently.Pressure,
+		Humidity:    int(f.Currently.Humidity * 100),
+		Weather:     f.Currently.Summary,
+		WindSpeed:   f.Currently.WindSpeed,
+		WindDeg:     float64(f.Currently.WindBearing),
+		Cloudiness:  int(f.Currently.CloudCover * 100),
+		Rainfall:    f.Currently.PrecipIntensity,
+	}
+}
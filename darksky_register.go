@@ -0,0 +1,29 @@
+// Copyright 2019 Yoshi Yamaguchi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build deprecated
+// +build deprecated
+
+package main
+
+import "time"
+
+// registerDarkSky registers the deprecated DarkSky provider when susanoo
+// is built with -tags deprecated.
+func registerDarkSky(cfg *Config) {
+	RegisterProvider(NewDarkSkyProvider(
+		cfg.Providers.DarkSkyAPIKey,
+		time.Duration(cfg.Providers.DarkSkyPollIntervalSeconds)*time.Second,
+	))
+}
@@ -0,0 +1,99 @@
+// Copyright 2019 Yoshi Yamaguchi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"strconv"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+const (
+	// Measure names for the forecast OpenCensus Measures.
+	MeasureForecastTemperature       = "forecast_temperature"
+	MeasureForecastPrecipProbability = "forecast_precip_probability"
+
+	// PrecipProbabilityUnit is a percentage, like HumidityUnit.
+	PrecipProbabilityUnit = "%"
+)
+
+var (
+	MForecastTemperature       = stats.Float64(MeasureForecastTemperature, "forecast air temperature", TemperatureUnit)
+	MForecastPrecipProbability = stats.Float64(MeasureForecastPrecipProbability, "forecast precipitation probability", PrecipProbabilityUnit)
+
+	// ForecastTemperatureView keeps a Distribution, rather than a
+	// LastValue, because every tick records one point per offset in the
+	// forecast horizon and Stackdriver dashboards want to show that
+	// whole band, not just the most recent point.
+	ForecastTemperatureView = &view.View{
+		Name:        MeasureForecastTemperature,
+		Measure:     MForecastTemperature,
+		Description: "forecast air temperature over the forecast horizon",
+		Aggregation: view.Distribution(-20, -10, 0, 5, 10, 15, 20, 25, 30, 35, 40),
+	}
+
+	ForecastPrecipProbabilityView = &view.View{
+		Name:        MeasureForecastPrecipProbability,
+		Measure:     MForecastPrecipProbability,
+		Description: "forecast precipitation probability over the forecast horizon",
+		Aggregation: view.Distribution(0, 10, 20, 30, 40, 50, 60, 70, 80, 90, 100),
+	}
+
+	// KeyForecastOffsetHours records how far into the future a forecast
+	// measurement looks, so Stackdriver can plot the full forecast band
+	// instead of collapsing it to a single point.
+	KeyForecastOffsetHours, _ = tag.NewKey("forecast_offset_hours")
+)
+
+// ForecastPoint is one predicted weather sample at a point in the
+// future.
+type ForecastPoint struct {
+	// OffsetHours is how far ahead of now this point is predicted for.
+	OffsetHours float64
+
+	Temperature       float64
+	PrecipProbability float64
+}
+
+// ForecastProvider is implemented by providers that can also return a
+// forecast, in addition to current conditions, for a Coordinate. A
+// WeatherProvider does not have to implement it; the poller checks for
+// it with a type assertion.
+type ForecastProvider interface {
+	Forecast(ctx context.Context, coord *Coordinate) ([]ForecastPoint, error)
+}
+
+// RecordForecastMeasurement records one forecast sample for provider id
+// at location, tagged with how far into the future it predicts.
+func RecordForecastMeasurement(id, location string, pt ForecastPoint) error {
+	ctx, err := tag.New(context.Background(),
+		tag.Upsert(KeyNodeId, id),
+		tag.Upsert(KeyLocation, location),
+		tag.Upsert(KeyForecastOffsetHours, strconv.FormatFloat(pt.OffsetHours, 'f', 1, 64)),
+	)
+	if err != nil {
+		logger.Errorf("failed to insert key: %v", err)
+		return err
+	}
+
+	stats.Record(ctx,
+		MForecastTemperature.M(pt.Temperature),
+		MForecastPrecipProbability.M(pt.PrecipProbability),
+	)
+	return nil
+}
@@ -0,0 +1,91 @@
+// Copyright 2019 Yoshi Yamaguchi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+)
+
+// weatherCacheKey identifies one (provider, location) pair in the
+// latest-reading cache.
+type weatherCacheKey struct {
+	Provider string
+	Location string
+}
+
+// weatherCache holds the most recently recorded *Weather for every
+// (provider, location) pair susanoo has polled, for the /weather
+// endpoint to serve without touching the providers themselves.
+type weatherCache struct {
+	mu    sync.RWMutex
+	byKey map[weatherCacheKey]*Weather
+}
+
+func newWeatherCache() *weatherCache {
+	return &weatherCache{byKey: make(map[weatherCacheKey]*Weather)}
+}
+
+// Set records w as the latest reading for (provider, location).
+func (c *weatherCache) Set(provider, location string, w *Weather) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.byKey[weatherCacheKey{provider, location}] = w
+}
+
+// Snapshot returns the latest readings grouped by location, then
+// provider, for JSON serving.
+func (c *weatherCache) Snapshot() map[string]map[string]*Weather {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	out := make(map[string]map[string]*Weather)
+	for k, w := range c.byKey {
+		byProvider, ok := out[k.Location]
+		if !ok {
+			byProvider = make(map[string]*Weather)
+			out[k.Location] = byProvider
+		}
+		byProvider[k.Provider] = w
+	}
+	return out
+}
+
+// latestWeather is the cache the HTTP server reads from and pollAndFallback
+// writes to.
+var latestWeather = newWeatherCache()
+
+// NewHTTPServer builds the susanoo HTTP server: /weather for the latest
+// readings as JSON, /healthz for liveness, and /metrics via metricsHandler.
+func NewHTTPServer(addr string, metricsHandler http.Handler) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/weather", handleWeather)
+	mux.HandleFunc("/healthz", handleHealthz)
+	mux.Handle("/metrics", metricsHandler)
+	return &http.Server{Addr: addr, Handler: mux}
+}
+
+func handleWeather(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(latestWeather.Snapshot()); err != nil {
+		logger.Errorf("failed to encode /weather response: %v", err)
+	}
+}
+
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok"))
+}
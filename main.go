@@ -16,17 +16,19 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"go.uber.org/zap/zapcore"
 
+	"contrib.go.opencensus.io/exporter/prometheus"
 	"contrib.go.opencensus.io/exporter/stackdriver"
-	owm "github.com/briandowns/openweathermap"
 	"go.opencensus.io/stats"
 	"go.opencensus.io/stats/view"
 	"go.opencensus.io/tag"
@@ -99,43 +101,20 @@ var (
 		PressureView,
 		//HumidityView,
 		WindSpeedView,
+		ForecastTemperatureView,
+		ForecastPrecipProbabilityView,
 	}
 
 	// KeyNodeId is the key for label in "generic_node",
 	KeyNodeId, _ = tag.NewKey("node_id")
-)
-
-const (
-	// Interval time period to fetch data from OpenWeatherMap.
-	// Free tier updates API data in 2 hours or less time interval.
-	// ref: https://openweathermap.org/price
-	OWMPollInterval = 15 * time.Second
-
-	// https://darksky.net/dev/docs#forecast-request
-	DarkSkyForecastAPIURL = "https://api.darksky.net/forecast/%s/%f,%f?exclude=minutely,hourly,daily,alerts&lang=en&units=si"
 
-	// DarkSky has limit of 1000 call per dar for free tier.
-	// https://darksky.net/dev/docs/faq#cost
-	DarkSkyPollInterval = 90 * time.Second
+	// KeyLocation distinguishes which configured location a measurement
+	// was recorded for, so a single binary can report metrics for many
+	// cities.
+	KeyLocation, _ = tag.NewKey("location")
 )
 
 var (
-	// Using Makefile in the repo embeds OWM_API_KEY on build.
-	OWMAPIKey string
-
-	// Using Makefile in the repo embds DARK_SKY_API_KEY on build.
-	DarkSkyAPIKey string
-
-	// Shibuya, Tokyo, Japan
-	TargetCityLatitude  = 35.6620
-	TargetCityLongitude = 139.7038
-
-	// Convert TargetCityLatitude and TargetCityLongitude to owm.Corrdinates.
-	TargetCoodinates *owm.Coordinates = &owm.Coordinates{
-		Longitude: TargetCityLongitude,
-		Latitude:  TargetCityLatitude,
-	}
-
 	logger *zap.SugaredLogger
 )
 
@@ -178,38 +157,82 @@ func init() {
 }
 
 func main() {
-	owmw, owmuv, err := InitOpenWeatherMap()
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		runReplay(os.Args[2:])
+		return
+	}
+
+	filename := flag.String("filename", "susanoo.yaml", "path to the susanoo config file")
+	listen := flag.String("listen", ":8080", "address for the HTTP server to listen on")
+	gracefulTimeout := flag.Duration("graceful-timeout", 15*time.Second, "how long to wait for in-flight HTTP requests during shutdown")
+	flag.Parse()
+
+	cfg, err := LoadConfig(*filename)
 	if err != nil {
-		logger.Fatalf("failed to initialize OpenWeatherMap: %v", err)
+		logger.Fatalf("failed to load config %s: %v", *filename, err)
 	}
 
+	RegisterProvider(NewOpenWeatherMapProvider(
+		cfg.Providers.OWMAPIKey,
+		cfg.Language,
+		time.Duration(cfg.Providers.OWMPollIntervalSeconds)*time.Second,
+	))
+	RegisterProvider(NewOpenMeteoProvider(
+		time.Duration(cfg.Providers.OpenMeteoPollIntervalSeconds) * time.Second,
+	))
+	RegisterProvider(NewMetNoProvider(
+		cfg.Providers.MetNoUserAgent,
+		time.Duration(cfg.Providers.MetNoPollIntervalSeconds)*time.Second,
+	))
+	registerDarkSky(cfg)
+
 	exporter := InitExporter()
 	defer exporter.Flush()
 	InitOpenCensusStats(exporter)
 
-	owmTicker := time.NewTicker(OWMPollInterval)
-	dsTicker := time.NewTicker(DarkSkyPollInterval)
+	promExporter := InitPrometheusExporter()
+
+	RegisterSink(OpenCensusSink{})
+	store, err := NewSQLiteSink(cfg.StorePath())
+	if err != nil {
+		logger.Fatalf("failed to open local store: %v", err)
+	}
+	defer store.Close()
+	RegisterSink(store)
+
+	httpServer := NewHTTPServer(*listen, promExporter)
+	go func() {
+		logger.Infof("starting HTTP server on %s", *listen)
+		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Errorf("HTTP server error: %v", err)
+		}
+	}()
+
+	pollers := reloadLocations(map[string]*locationPoller{}, cfg.Locations)
+
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+
+	sigterm := make(chan os.Signal, 1)
+	signal.Notify(sigterm, syscall.SIGTERM)
+
 	for {
 		select {
-		case <-owmTicker.C:
-			if err := owmw.CurrentByCoordinates(TargetCoodinates); err != nil {
-				logger.Errorf("failed to call current data from OpenWeatherMap: %v", err)
-				break
-			}
-			w := OWMToWeather(owmw, owmuv)
-			if err := RecordMeasurement("openweathermap", w); err != nil {
-				logger.Errorf("failed to record: %v", err)
-			}
-		case <-dsTicker.C:
-			f, err := CallDarkSkyForecast()
+		case <-sighup:
+			cfg, err := LoadConfig(*filename)
 			if err != nil {
-				logger.Errorf("failed to call DarkSky: %v", err)
-				break
+				logger.Errorf("failed to reload config %s: %v", *filename, err)
+				continue
 			}
-			w := DSToWeather(f)
-			if err := RecordMeasurement("darksky", w); err != nil {
-				logger.Errorf("failed to record: %v", err)
+			pollers = reloadLocations(pollers, cfg.Locations)
+		case <-sigterm:
+			logger.Infof("shutting down HTTP server, waiting up to %s for in-flight requests", *gracefulTimeout)
+			ctx, cancel := context.WithTimeout(context.Background(), *gracefulTimeout)
+			if err := httpServer.Shutdown(ctx); err != nil {
+				logger.Errorf("failed to shut down HTTP server gracefully: %v", err)
 			}
+			cancel()
+			return
 		}
 	}
 }
@@ -245,14 +268,50 @@ func InitExporter() *stackdriver.Exporter {
 	return exporter
 }
 
+// InitPrometheusExporter registers an OpenCensus Prometheus exporter
+// alongside the Stackdriver one and returns its http.Handler for the
+// /metrics endpoint.
+func InitPrometheusExporter() *prometheus.Exporter {
+	exporter, err := prometheus.NewExporter(prometheus.Options{
+		Namespace: "susanoo",
+	})
+	if err != nil {
+		log.Fatalf("failed to initialize Prometheus exporter: %v", err)
+	}
+	view.RegisterExporter(exporter)
+	return exporter
+}
+
 func InitOpenCensusStats(exporter *stackdriver.Exporter) {
 	view.SetReportingPeriod(OCReportInterval)
 	view.RegisterExporter(exporter)
 	view.Register(WeatherReportViews...)
 }
 
-func RecordMeasurement(id string, w *Weather) error {
-	ctx, err := tag.New(context.Background(), tag.Upsert(KeyNodeId, id))
+// RecordMeasurement fans out one reading to every sink in
+// RegisteredSinks. A sink failing to record does not stop the others
+// from receiving the reading; the first error encountered is returned.
+func RecordMeasurement(id, location string, w *Weather) error {
+	var firstErr error
+	for _, sink := range RegisteredSinks {
+		if err := sink.Record(context.Background(), id, location, w); err != nil {
+			logger.Errorf("sink %s failed to record: %v", sink.Name(), err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// recordOpenCensusMeasurement is the OpenCensusSink's Record
+// implementation; it lives here alongside the Measures and Views it
+// records against.
+func recordOpenCensusMeasurement(ctx context.Context, id, location string, w *Weather) error {
+	ctx, err := tag.New(ctx,
+		tag.Upsert(KeyNodeId, id),
+		tag.Upsert(KeyLocation, location),
+	)
 	if err != nil {
 		logger.Errorf("failed to insert key: %v", err)
 		return err
@@ -266,85 +325,3 @@ func RecordMeasurement(id string, w *Weather) error {
 	)
 	return nil
 }
-
-func InitOpenWeatherMap() (*owm.CurrentWeatherData, *owm.UV, error) {
-	w, err := owm.NewCurrent("C", "EN", OWMAPIKey)
-	if err != nil {
-		logger.Errorf("failed to initialize OpenWeatherMap current weather data: %v", err)
-		return nil, nil, err
-	}
-	w.CurrentByCoordinates(TargetCoodinates)
-
-	uv, err := owm.NewUV(OWMAPIKey)
-	if err != nil {
-		logger.Errorf("failed to initialize OpenWeatherMap UV data: %s", err)
-		return nil, nil, err
-	}
-	uv.Current(TargetCoodinates)
-	return w, uv, nil
-}
-
-type DarkSkyForecast struct {
-	Latitude  float64 `json:"latitude"`
-	Longitude float64 `json:"longitude"`
-	TimeZone  string  `json:"timezone"`
-	Currently struct {
-		Time            int64   `json:"time"`
-		Summary         string  `json:"summary"`
-		Icon            string  `json:"icon"`
-		Temperature     float64 `json:"temperature"`
-		Pressure        float64 `json:"pressure"`
-		Humidity        float64 `json:"humidity"`
-		WindSpeed       float64 `json:"windSpeed"`
-		WindBearing     int     `json:"windBearing"`
-		PrecipIntensity float64 `json:"precipIntensity"`
-		CloudCover      float64 `json:"cloudCover"`
-		UVIndex         float64 `json:"uvIndex"`
-	} `json:"currently"`
-}
-
-func CallDarkSkyForecast() (*DarkSkyForecast, error) {
-	resp, err := http.Get(
-		fmt.Sprintf(DarkSkyForecastAPIURL, DarkSkyAPIKey, TargetCityLatitude, TargetCityLongitude))
-	if err != nil {
-		logger.Errorf("failed to call DarkSky forecast API: %s", err)
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	decoder := json.NewDecoder(resp.Body)
-	var f DarkSkyForecast
-	err = decoder.Decode(&f)
-	if err != nil {
-		logger.Errorf("failed to decode DarkSky reponse: %s", err)
-		return nil, err
-	}
-	return &f, nil
-}
-
-func OWMToWeather(w *owm.CurrentWeatherData, uv *owm.UV) *Weather {
-	return &Weather{
-		Temperature: w.Main.Temp,
-		Pressure:    w.Main.Pressure,
-		Humidity:    w.Main.Humidity,
-		Weather:     w.Weather[0].Main,
-		WindSpeed:   w.Wind.Speed,
-		WindDeg:     w.Wind.Deg,
-		Cloudiness:  w.Clouds.All,
-		Rainfall:    w.Rain.ThreeH / 3,
-		UV:          uv.Value,
-	}
-}
-
-func DSToWeather(f *DarkSkyForecast) *Weather {
-	return &Weather{
-		Temperature: f.Currently.Temperature,
-		Pressure:    f.Currently.Pressure,
-		Humidity:    int(f.Currently.Humidity * 100),
-		Weather:     f.Currently.Summary,
-		WindSpeed:   f.Currently.WindSpeed,
-		WindDeg:     float64(f.Currently.WindBearing),
-		Cloudiness:  int(f.Currently.CloudCover * 100),
-		Rainfall:    f.Currently.PrecipIntensity,
-	}
-}
@@ -0,0 +1,312 @@
+// Copyright 2019 Yoshi Yamaguchi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// MetNoForecastAPIURL is the Met.no Locationforecast compact
+	// endpoint. https://api.met.no/weatherapi/locationforecast/2.0/documentation
+	MetNoForecastAPIURL = "https://api.met.no/weatherapi/locationforecast/2.0/compact?lat=%f&lon=%f"
+
+	// DefaultMetNoUserAgent is used when the config doesn't set one.
+	// Met.no's terms of service require an identifiable User-Agent;
+	// operators running susanoo against Met.no in production should set
+	// metno_user_agent in their config to their own contact details.
+	DefaultMetNoUserAgent = "susanoo/1.0 (+https://github.com/ymotongpoo/susanoo)"
+
+	// MetNoPollInterval is the interval to fetch data from Met.no.
+	MetNoPollInterval = 5 * time.Minute
+
+	// MetNoMaxAttempts bounds the jittered retry loop in get.
+	MetNoMaxAttempts = 3
+)
+
+// MetNoProvider implements WeatherProvider and ForecastProvider on top
+// of the Met.no Locationforecast API.
+type MetNoProvider struct {
+	client       *http.Client
+	userAgent    string
+	pollInterval time.Duration
+
+	mu    sync.Mutex
+	cache map[Coordinate]*metNoCacheEntry
+}
+
+// metNoCacheEntry is the last response fetched for one Coordinate. Met.no
+// requires callers to honor Expires and to send back If-Modified-Since
+// on the next request, rather than polling the raw endpoint every tick.
+//
+// A *metNoCacheEntry is never mutated after it's stored in
+// MetNoProvider.cache: get may hand a cached entry to a concurrent
+// caller (a location's Met.no ticker and another provider's fallback
+// can both call get for the same Coordinate), so refreshing it always
+// builds a new entry and swaps it into the map under p.mu instead.
+type metNoCacheEntry struct {
+	lastModified string
+	expires      time.Time
+	response     *metNoResponse
+}
+
+// NewMetNoProvider returns a MetNoProvider. userAgent defaults to
+// DefaultMetNoUserAgent when empty, and pollInterval overrides
+// MetNoPollInterval when positive.
+func NewMetNoProvider(userAgent string, pollInterval time.Duration) *MetNoProvider {
+	if userAgent == "" {
+		userAgent = DefaultMetNoUserAgent
+	}
+	if pollInterval <= 0 {
+		pollInterval = MetNoPollInterval
+	}
+	return &MetNoProvider{
+		client:       http.DefaultClient,
+		userAgent:    userAgent,
+		pollInterval: pollInterval,
+		cache:        make(map[Coordinate]*metNoCacheEntry),
+	}
+}
+
+func (p *MetNoProvider) Name() string {
+	return "met.no"
+}
+
+func (p *MetNoProvider) MinPollInterval() time.Duration {
+	return p.pollInterval
+}
+
+func (p *MetNoProvider) Fetch(ctx context.Context, coord *Coordinate) (*Weather, error) {
+	resp, err := p.get(ctx, *coord)
+	if err != nil {
+		return nil, err
+	}
+	return metNoToWeather(resp), nil
+}
+
+func (p *MetNoProvider) Forecast(ctx context.Context, coord *Coordinate) ([]ForecastPoint, error) {
+	resp, err := p.get(ctx, *coord)
+	if err != nil {
+		return nil, err
+	}
+	return metNoToForecastPoints(resp)
+}
+
+// get returns the cached response for coord if Met.no's Expires header
+// says it's still fresh, otherwise calls the API. It sends
+// If-Modified-Since when a cached response exists, and on a 429/503
+// retries with jitter honoring Retry-After, up to MetNoMaxAttempts.
+func (p *MetNoProvider) get(ctx context.Context, coord Coordinate) (*metNoResponse, error) {
+	p.mu.Lock()
+	entry := p.cache[coord]
+	p.mu.Unlock()
+
+	if entry != nil && time.Now().Before(entry.expires) {
+		return entry.response, nil
+	}
+
+	url := fmt.Sprintf(MetNoForecastAPIURL, coord.Latitude, coord.Longitude)
+	var lastErr error
+	for attempt := 0; attempt < MetNoMaxAttempts; attempt++ {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		req = req.WithContext(ctx)
+		req.Header.Set("User-Agent", p.userAgent)
+		if entry != nil && entry.lastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.lastModified)
+		}
+
+		resp, err := p.client.Do(req)
+		if err != nil {
+			lastErr = err
+			logger.Errorf("failed to call Met.no forecast API: %v", err)
+			continue
+		}
+
+		switch resp.StatusCode {
+		case http.StatusNotModified:
+			resp.Body.Close()
+			if entry == nil {
+				lastErr = fmt.Errorf("met.no returned 304 Not Modified with no cached response to reuse")
+				continue
+			}
+			// entry is shared with concurrent callers (e.g. a fallback
+			// from another provider's ticker), so build a fresh entry
+			// rather than mutating fields on the one they may be
+			// reading, and swap it into the map under p.mu.
+			refreshed := &metNoCacheEntry{
+				lastModified: entry.lastModified,
+				expires:      metNoExpires(resp.Header.Get("Expires")),
+				response:     entry.response,
+			}
+			p.mu.Lock()
+			p.cache[coord] = refreshed
+			p.mu.Unlock()
+			return refreshed.response, nil
+
+		case http.StatusOK:
+			var out metNoResponse
+			err := json.NewDecoder(resp.Body).Decode(&out)
+			resp.Body.Close()
+			if err != nil {
+				lastErr = err
+				logger.Errorf("failed to decode Met.no response: %v", err)
+				continue
+			}
+			newEntry := &metNoCacheEntry{
+				lastModified: resp.Header.Get("Last-Modified"),
+				expires:      metNoExpires(resp.Header.Get("Expires")),
+				response:     &out,
+			}
+			p.mu.Lock()
+			p.cache[coord] = newEntry
+			p.mu.Unlock()
+			return &out, nil
+
+		case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+			retryAfter := metNoRetryAfter(resp.Header.Get("Retry-After"))
+			resp.Body.Close()
+			lastErr = fmt.Errorf("met.no returned %d", resp.StatusCode)
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(jitter(retryAfter)):
+			}
+
+		default:
+			resp.Body.Close()
+			lastErr = fmt.Errorf("met.no returned unexpected status %d", resp.StatusCode)
+		}
+	}
+	return nil, lastErr
+}
+
+// jitter adds up to d/2 of random delay on top of d, so a burst of
+// providers hitting Retry-After at once doesn't retry in lockstep.
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		d = time.Second
+	}
+	return d + time.Duration(rand.Int63n(int64(d)/2+1))
+}
+
+// metNoRetryAfter parses a Retry-After header, which may be either a
+// number of seconds or an HTTP date, defaulting to 1 second.
+func metNoRetryAfter(v string) time.Duration {
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t)
+	}
+	return time.Second
+}
+
+// metNoExpires parses an Expires header, defaulting to a 1 minute
+// freshness window when absent or unparsable.
+func metNoExpires(v string) time.Time {
+	if t, err := http.ParseTime(v); err == nil {
+		return t
+	}
+	return time.Now().Add(time.Minute)
+}
+
+type metNoResponse struct {
+	Properties struct {
+		Timeseries []metNoTimestep `json:"timeseries"`
+	} `json:"properties"`
+}
+
+type metNoTimestep struct {
+	Time string `json:"time"`
+	Data struct {
+		Instant struct {
+			Details struct {
+				AirPressureAtSeaLevel float64 `json:"air_pressure_at_sea_level"`
+				AirTemperature        float64 `json:"air_temperature"`
+				RelativeHumidity      float64 `json:"relative_humidity"`
+				WindFromDirection     float64 `json:"wind_from_direction"`
+				WindSpeed             float64 `json:"wind_speed"`
+			} `json:"details"`
+		} `json:"instant"`
+		Next1Hours struct {
+			Details struct {
+				PrecipitationAmount float64 `json:"precipitation_amount"`
+			} `json:"details"`
+		} `json:"next_1_hours"`
+	} `json:"data"`
+}
+
+// metNoToWeather maps the nearest (first) timestep to Weather. Met.no
+// already reports temperature in C, pressure in hPa, and wind speed in
+// m/s, matching susanoo's units.
+func metNoToWeather(r *metNoResponse) *Weather {
+	if len(r.Properties.Timeseries) == 0 {
+		return &Weather{}
+	}
+	d := r.Properties.Timeseries[0].Data.Instant.Details
+	return &Weather{
+		Temperature: d.AirTemperature,
+		Pressure:    d.AirPressureAtSeaLevel,
+		Humidity:    int(d.RelativeHumidity),
+		WindSpeed:   d.WindSpeed,
+		WindDeg:     d.WindFromDirection,
+	}
+}
+
+func metNoToForecastPoints(r *metNoResponse) ([]ForecastPoint, error) {
+	if len(r.Properties.Timeseries) == 0 {
+		return nil, nil
+	}
+
+	now, err := time.Parse(time.RFC3339, r.Properties.Timeseries[0].Time)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Met.no timestep time %q: %w", r.Properties.Timeseries[0].Time, err)
+	}
+
+	points := make([]ForecastPoint, 0, len(r.Properties.Timeseries))
+	for _, ts := range r.Properties.Timeseries {
+		t, err := time.Parse(time.RFC3339, ts.Time)
+		if err != nil {
+			continue
+		}
+		points = append(points, ForecastPoint{
+			OffsetHours:       t.Sub(now).Hours(),
+			Temperature:       ts.Data.Instant.Details.AirTemperature,
+			PrecipProbability: precipAmountToProbability(ts.Data.Next1Hours.Details.PrecipitationAmount),
+		})
+	}
+	return points, nil
+}
+
+// precipAmountToProbability approximates a 0-100 precipitation
+// probability from the next hour's precipitation volume in mm, since
+// the compact Locationforecast format has no probability field.
+func precipAmountToProbability(mm float64) float64 {
+	p := mm * 20
+	if p > 100 {
+		return 100
+	}
+	return p
+}
@@ -0,0 +1,201 @@
+// Copyright 2019 Yoshi Yamaguchi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestJitter(t *testing.T) {
+	tests := []struct {
+		name string
+		in   time.Duration
+	}{
+		{"positive", 4 * time.Second},
+		{"zero defaults to a second", 0},
+		{"negative defaults to a second", -time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := tt.in
+			if base <= 0 {
+				base = time.Second
+			}
+			for i := 0; i < 20; i++ {
+				got := jitter(tt.in)
+				if got < base {
+					t.Fatalf("jitter(%s) = %s, want >= %s", tt.in, got, base)
+				}
+				if got > base+base/2+1 {
+					t.Fatalf("jitter(%s) = %s, want <= %s", tt.in, got, base+base/2+1)
+				}
+			}
+		})
+	}
+}
+
+func TestMetNoRetryAfter(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want time.Duration
+	}{
+		{"seconds", "2", 2 * time.Second},
+		{"empty falls back to a second", "", time.Second},
+		{"garbage falls back to a second", "not-a-value", time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := metNoRetryAfter(tt.in); got != tt.want {
+				t.Errorf("metNoRetryAfter(%q) = %s, want %s", tt.in, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("HTTP date", func(t *testing.T) {
+		future := time.Now().Add(30 * time.Second).Truncate(time.Second)
+		got := metNoRetryAfter(future.UTC().Format(http.TimeFormat))
+		if got <= 0 || got > 31*time.Second {
+			t.Errorf("metNoRetryAfter(%s) = %s, want roughly 30s", future, got)
+		}
+	})
+}
+
+func TestMetNoExpires(t *testing.T) {
+	t.Run("valid HTTP date", func(t *testing.T) {
+		want := time.Now().Add(time.Hour).Truncate(time.Second).UTC()
+		got := metNoExpires(want.Format(http.TimeFormat))
+		if !got.Equal(want) {
+			t.Errorf("metNoExpires(%s) = %s, want %s", want.Format(http.TimeFormat), got, want)
+		}
+	})
+
+	t.Run("empty defaults to roughly a minute from now", func(t *testing.T) {
+		before := time.Now()
+		got := metNoExpires("")
+		if got.Before(before.Add(30*time.Second)) || got.After(before.Add(90*time.Second)) {
+			t.Errorf("metNoExpires(\"\") = %s, want roughly 1m from %s", got, before)
+		}
+	})
+}
+
+func TestMetNoToForecastPoints(t *testing.T) {
+	resp := &metNoResponse{}
+	resp.Properties.Timeseries = make([]metNoTimestep, 2)
+	resp.Properties.Timeseries[0].Time = "2026-07-26T00:00:00Z"
+	resp.Properties.Timeseries[0].Data.Instant.Details.AirTemperature = 20
+	resp.Properties.Timeseries[0].Data.Next1Hours.Details.PrecipitationAmount = 1
+	resp.Properties.Timeseries[1].Time = "2026-07-26T03:00:00Z"
+	resp.Properties.Timeseries[1].Data.Instant.Details.AirTemperature = 18
+	resp.Properties.Timeseries[1].Data.Next1Hours.Details.PrecipitationAmount = 6
+
+	points, err := metNoToForecastPoints(resp)
+	if err != nil {
+		t.Fatalf("metNoToForecastPoints: %v", err)
+	}
+	if len(points) != 2 {
+		t.Fatalf("got %d points, want 2", len(points))
+	}
+	if points[0].OffsetHours != 0 {
+		t.Errorf("points[0].OffsetHours = %v, want 0", points[0].OffsetHours)
+	}
+	if points[0].Temperature != 20 || points[0].PrecipProbability != 20 {
+		t.Errorf("points[0] = %+v, want Temperature 20, PrecipProbability 20", points[0])
+	}
+	if points[1].OffsetHours != 3 {
+		t.Errorf("points[1].OffsetHours = %v, want 3", points[1].OffsetHours)
+	}
+	if points[1].Temperature != 18 || points[1].PrecipProbability != 100 {
+		t.Errorf("points[1] = %+v, want Temperature 18, PrecipProbability 100 (clamped)", points[1])
+	}
+}
+
+func TestMetNoProviderGet(t *testing.T) {
+	var requests int
+	expires := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch requests {
+		case 1:
+			w.Header().Set("Last-Modified", "Sun, 26 Jul 2026 00:00:00 GMT")
+			w.Header().Set("Expires", time.Now().UTC().Format(http.TimeFormat)) // already stale
+			json.NewEncoder(w).Encode(metNoResponse{})
+		case 2:
+			if r.Header.Get("If-Modified-Since") == "" {
+				t.Errorf("request 2: want If-Modified-Since set from the cached entry, got none")
+			}
+			w.Header().Set("Expires", expires)
+			w.WriteHeader(http.StatusNotModified)
+		default:
+			t.Errorf("unexpected request %d, cached entry should have been fresh", requests)
+		}
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	p := NewMetNoProvider("susanoo-test/1.0", time.Minute)
+	// get() builds its own URL from MetNoForecastAPIURL and coord, so
+	// point the provider's client at the test server via a transport
+	// that rewrites the scheme/host instead.
+	p.client = &http.Client{Transport: rewriteHostTransport{srv.URL}}
+
+	coord := Coordinate{Latitude: 35.0, Longitude: 139.0}
+	ctx := context.Background()
+
+	if _, err := p.get(ctx, coord); err != nil {
+		t.Fatalf("first get: %v", err)
+	}
+	if requests != 1 {
+		t.Fatalf("requests = %d after first get, want 1", requests)
+	}
+
+	if _, err := p.get(ctx, coord); err != nil {
+		t.Fatalf("second get (expect 304): %v", err)
+	}
+	if requests != 2 {
+		t.Fatalf("requests = %d after second get, want 2", requests)
+	}
+
+	if _, err := p.get(ctx, coord); err != nil {
+		t.Fatalf("third get (expect cache hit): %v", err)
+	}
+	if requests != 2 {
+		t.Fatalf("requests = %d after third get, want 2 (cache hit from the refreshed Expires)", requests)
+	}
+}
+
+// rewriteHostTransport redirects every request to target's host, so
+// tests can exercise MetNoProvider.get against an httptest.Server
+// without reimplementing URL construction.
+type rewriteHostTransport struct {
+	target string
+}
+
+func (rt rewriteHostTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	u, err := req.URL.Parse(rt.target)
+	if err != nil {
+		return nil, err
+	}
+	req.URL.Scheme = u.Scheme
+	req.URL.Host = u.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
@@ -0,0 +1,194 @@
+// Copyright 2019 Yoshi Yamaguchi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// OpenMeteoForecastAPIURL is the Open-Meteo forecast endpoint. It
+	// needs no API key. windspeed_unit=ms matches WindSpeedUnit so
+	// openMeteoToWeather doesn't need to convert it.
+	OpenMeteoForecastAPIURL = "https://api.open-meteo.com/v1/forecast?latitude=%f&longitude=%f&current_weather=true&hourly=temperature_2m,relativehumidity_2m,surface_pressure,precipitation_probability&windspeed_unit=ms&timezone=UTC"
+
+	// OpenMeteoPollInterval is the interval to fetch data from
+	// Open-Meteo. It has no published rate limit for non-commercial use.
+	OpenMeteoPollInterval = 60 * time.Second
+)
+
+// OpenMeteoProvider implements WeatherProvider and ForecastProvider on
+// top of the key-free Open-Meteo forecast API.
+type OpenMeteoProvider struct {
+	client       *http.Client
+	pollInterval time.Duration
+
+	mu    sync.Mutex
+	cache map[Coordinate]openMeteoCacheEntry
+}
+
+// openMeteoCacheEntry is the last response call fetched for one
+// Coordinate. Unlike Met.no, Open-Meteo sends no freshness header, so
+// entries are kept fresh for pollInterval: long enough that the
+// Forecast call recordForecastIfSupported makes right after a
+// successful Fetch reuses it instead of issuing a second request, but
+// short enough that the next tick fetches again.
+type openMeteoCacheEntry struct {
+	response *openMeteoResponse
+	expires  time.Time
+}
+
+// NewOpenMeteoProvider returns an OpenMeteoProvider. pollInterval
+// overrides OpenMeteoPollInterval when positive.
+func NewOpenMeteoProvider(pollInterval time.Duration) *OpenMeteoProvider {
+	if pollInterval <= 0 {
+		pollInterval = OpenMeteoPollInterval
+	}
+	return &OpenMeteoProvider{
+		client:       http.DefaultClient,
+		pollInterval: pollInterval,
+		cache:        make(map[Coordinate]openMeteoCacheEntry),
+	}
+}
+
+func (p *OpenMeteoProvider) Name() string {
+	return "open-meteo"
+}
+
+func (p *OpenMeteoProvider) MinPollInterval() time.Duration {
+	return p.pollInterval
+}
+
+func (p *OpenMeteoProvider) Fetch(ctx context.Context, coord *Coordinate) (*Weather, error) {
+	resp, err := p.call(ctx, coord)
+	if err != nil {
+		return nil, err
+	}
+	return openMeteoToWeather(resp), nil
+}
+
+// Forecast implements ForecastProvider from the hourly block of the
+// same response call caches for this tick's Fetch, rather than issuing
+// a second Open-Meteo request.
+func (p *OpenMeteoProvider) Forecast(ctx context.Context, coord *Coordinate) ([]ForecastPoint, error) {
+	resp, err := p.call(ctx, coord)
+	if err != nil {
+		return nil, err
+	}
+
+	now, err := time.Parse(openMeteoTimeLayout, resp.CurrentWeather.Time)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Open-Meteo current_weather time %q: %w", resp.CurrentWeather.Time, err)
+	}
+
+	points := make([]ForecastPoint, 0, len(resp.Hourly.Time))
+	for i, ts := range resp.Hourly.Time {
+		t, err := time.Parse(openMeteoTimeLayout, ts)
+		if err != nil {
+			continue
+		}
+		pt := ForecastPoint{OffsetHours: t.Sub(now).Hours()}
+		if i < len(resp.Hourly.Temperature2m) {
+			pt.Temperature = resp.Hourly.Temperature2m[i]
+		}
+		if i < len(resp.Hourly.PrecipitationProbability) {
+			pt.PrecipProbability = resp.Hourly.PrecipitationProbability[i]
+		}
+		points = append(points, pt)
+	}
+	return points, nil
+}
+
+func (p *OpenMeteoProvider) call(ctx context.Context, coord *Coordinate) (*openMeteoResponse, error) {
+	p.mu.Lock()
+	entry, ok := p.cache[*coord]
+	p.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.response, nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf(OpenMeteoForecastAPIURL, coord.Latitude, coord.Longitude), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.client.Do(req.WithContext(ctx))
+	if err != nil {
+		logger.Errorf("failed to call Open-Meteo forecast API: %v", err)
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var out openMeteoResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		logger.Errorf("failed to decode Open-Meteo response: %v", err)
+		return nil, err
+	}
+
+	p.mu.Lock()
+	p.cache[*coord] = openMeteoCacheEntry{response: &out, expires: time.Now().Add(p.pollInterval)}
+	p.mu.Unlock()
+	return &out, nil
+}
+
+// openMeteoTimeLayout is the ISO 8601 (minus seconds) layout Open-Meteo
+// uses for every timestamp in its response, given timezone=UTC.
+const openMeteoTimeLayout = "2006-01-02T15:04"
+
+type openMeteoResponse struct {
+	CurrentWeather struct {
+		Temperature   float64 `json:"temperature"`
+		WindSpeed     float64 `json:"windspeed"`
+		WindDirection float64 `json:"winddirection"`
+		Time          string  `json:"time"`
+	} `json:"current_weather"`
+	Hourly struct {
+		Time                     []string  `json:"time"`
+		Temperature2m            []float64 `json:"temperature_2m"`
+		RelativeHumidity2m       []float64 `json:"relativehumidity_2m"`
+		SurfacePressure          []float64 `json:"surface_pressure"`
+		PrecipitationProbability []float64 `json:"precipitation_probability"`
+	} `json:"hourly"`
+}
+
+// openMeteoToWeather maps an openMeteoResponse to the existing Weather
+// struct. current_weather has no humidity or pressure field, so those
+// come from the hourly block at the index matching current_weather.time.
+func openMeteoToWeather(r *openMeteoResponse) *Weather {
+	w := &Weather{
+		Temperature: r.CurrentWeather.Temperature,
+		WindSpeed:   r.CurrentWeather.WindSpeed,
+		WindDeg:     r.CurrentWeather.WindDirection,
+	}
+
+	for i, t := range r.Hourly.Time {
+		if t != r.CurrentWeather.Time {
+			continue
+		}
+		if i < len(r.Hourly.RelativeHumidity2m) {
+			w.Humidity = int(r.Hourly.RelativeHumidity2m[i])
+		}
+		if i < len(r.Hourly.SurfacePressure) {
+			w.Pressure = r.Hourly.SurfacePressure[i]
+		}
+		break
+	}
+	return w
+}
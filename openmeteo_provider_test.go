@@ -0,0 +1,94 @@
+// Copyright 2019 Yoshi Yamaguchi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOpenMeteoToWeather(t *testing.T) {
+	resp := &openMeteoResponse{}
+	resp.CurrentWeather.Temperature = 22.5
+	resp.CurrentWeather.WindSpeed = 3.1
+	resp.CurrentWeather.WindDirection = 180
+	resp.CurrentWeather.Time = "2026-07-26T12:00"
+	resp.Hourly.Time = []string{"2026-07-26T11:00", "2026-07-26T12:00", "2026-07-26T13:00"}
+	resp.Hourly.RelativeHumidity2m = []float64{50, 60, 70}
+	resp.Hourly.SurfacePressure = []float64{1000, 1010, 1020}
+
+	w := openMeteoToWeather(resp)
+	if w.Temperature != 22.5 || w.WindSpeed != 3.1 || w.WindDeg != 180 {
+		t.Fatalf("got %+v, want current_weather fields copied as-is", w)
+	}
+	if w.Humidity != 60 {
+		t.Errorf("Humidity = %d, want 60 (the hourly[1] entry matching current_weather.time)", w.Humidity)
+	}
+	if w.Pressure != 1010 {
+		t.Errorf("Pressure = %v, want 1010 (the hourly[1] entry matching current_weather.time)", w.Pressure)
+	}
+}
+
+func TestOpenMeteoToWeather_NoMatchingHour(t *testing.T) {
+	resp := &openMeteoResponse{}
+	resp.CurrentWeather.Time = "2026-07-26T12:00"
+	resp.Hourly.Time = []string{"2026-07-26T11:00"}
+	resp.Hourly.RelativeHumidity2m = []float64{50}
+
+	w := openMeteoToWeather(resp)
+	if w.Humidity != 0 {
+		t.Errorf("Humidity = %d, want 0 when no hourly entry matches current_weather.time", w.Humidity)
+	}
+}
+
+func TestOpenMeteoProviderCall_CachesWithinPollInterval(t *testing.T) {
+	var requests int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		resp := openMeteoResponse{}
+		resp.CurrentWeather.Time = "2026-07-26T12:00"
+		json.NewEncoder(w).Encode(resp)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	p := NewOpenMeteoProvider(time.Minute)
+	p.client = &http.Client{Transport: rewriteHostTransport{srv.URL}}
+
+	coord := &Coordinate{Latitude: 35.0, Longitude: 139.0}
+	ctx := context.Background()
+
+	if _, err := p.Fetch(ctx, coord); err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if requests != 1 {
+		t.Fatalf("requests = %d after Fetch, want 1", requests)
+	}
+
+	// Forecast runs moments after Fetch on the same tick in
+	// recordForecastIfSupported; it must reuse Fetch's cached response
+	// instead of issuing a second request.
+	if _, err := p.Forecast(ctx, coord); err != nil {
+		t.Fatalf("Forecast: %v", err)
+	}
+	if requests != 1 {
+		t.Fatalf("requests = %d after Forecast, want 1 (cached)", requests)
+	}
+}
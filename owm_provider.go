@@ -0,0 +1,169 @@
+// Copyright 2019 Yoshi Yamaguchi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	owm "github.com/briandowns/openweathermap"
+)
+
+const (
+	// OWMPollInterval is the interval to fetch data from OpenWeatherMap.
+	// Free tier updates API data in 2 hours or less time interval.
+	// ref: https://openweathermap.org/price
+	OWMPollInterval = 15 * time.Second
+
+	// OWMForecastDays is how many days of the 5-day/3-hour forecast
+	// endpoint to request per call.
+	OWMForecastDays = 2
+
+	// owmForecastStepsPerDay is how many 3-hour entries the 5-day/3-hour
+	// endpoint returns per day. DailyByCoordinates's cnt parameter counts
+	// 3-hour entries, not days, despite its name.
+	owmForecastStepsPerDay = 8
+)
+
+// OpenWeatherMapProvider implements WeatherProvider on top of the
+// OpenWeatherMap current-conditions and UV index APIs, and
+// ForecastProvider on top of its 5-day/3-hour forecast endpoint.
+type OpenWeatherMapProvider struct {
+	w            *owm.CurrentWeatherData
+	uv           *owm.UV
+	forecast     *owm.ForecastWeatherData
+	pollInterval time.Duration
+}
+
+// NewOpenWeatherMapProvider initializes the underlying OpenWeatherMap
+// clients for current weather, UV index, and forecast data. lang is the
+// ISO 639-1 language code for weather descriptions; it defaults to "EN"
+// when empty. pollInterval overrides OWMPollInterval when positive.
+func NewOpenWeatherMapProvider(apiKey, lang string, pollInterval time.Duration) *OpenWeatherMapProvider {
+	if lang == "" {
+		lang = "EN"
+	}
+	w, err := owm.NewCurrent("C", lang, apiKey)
+	if err != nil {
+		logger.Fatalf("failed to initialize OpenWeatherMap current weather data: %v", err)
+	}
+
+	uv, err := owm.NewUV(apiKey)
+	if err != nil {
+		logger.Fatalf("failed to initialize OpenWeatherMap UV data: %v", err)
+	}
+
+	forecast, err := owm.NewForecast("5", "C", lang, apiKey)
+	if err != nil {
+		logger.Fatalf("failed to initialize OpenWeatherMap forecast data: %v", err)
+	}
+
+	if pollInterval <= 0 {
+		pollInterval = OWMPollInterval
+	}
+
+	return &OpenWeatherMapProvider{w: w, uv: uv, forecast: forecast, pollInterval: pollInterval}
+}
+
+func (p *OpenWeatherMapProvider) Name() string {
+	return "openweathermap"
+}
+
+func (p *OpenWeatherMapProvider) MinPollInterval() time.Duration {
+	return p.pollInterval
+}
+
+func (p *OpenWeatherMapProvider) Fetch(ctx context.Context, coord *Coordinate) (*Weather, error) {
+	var err error
+	if coord.OWMCityID != 0 {
+		err = p.w.CurrentByID(coord.OWMCityID)
+	} else {
+		err = p.w.CurrentByCoordinates(toOWMCoordinates(coord))
+	}
+	if err != nil {
+		logger.Errorf("failed to call current data from OpenWeatherMap: %v", err)
+		return nil, err
+	}
+	return OWMToWeather(p.w, p.uv), nil
+}
+
+// Forecast implements ForecastProvider using OpenWeatherMap's 5-day/
+// 3-hour forecast endpoint. The endpoint has no precipitation
+// probability field, so PrecipProbability is approximated from the
+// 3-hour rainfall volume.
+func (p *OpenWeatherMapProvider) Forecast(ctx context.Context, coord *Coordinate) ([]ForecastPoint, error) {
+	cnt := OWMForecastDays * owmForecastStepsPerDay
+	var err error
+	if coord.OWMCityID != 0 {
+		err = p.forecast.DailyByID(coord.OWMCityID, cnt)
+	} else {
+		err = p.forecast.DailyByCoordinates(toOWMCoordinates(coord), cnt)
+	}
+	if err != nil {
+		logger.Errorf("failed to call forecast data from OpenWeatherMap: %v", err)
+		return nil, err
+	}
+
+	data, ok := p.forecast.ForecastWeatherJson.(*owm.Forecast5WeatherData)
+	if !ok {
+		return nil, fmt.Errorf("unexpected OpenWeatherMap forecast response type %T", p.forecast.ForecastWeatherJson)
+	}
+
+	now := time.Now()
+	points := make([]ForecastPoint, 0, len(data.List))
+	for _, item := range data.List {
+		points = append(points, ForecastPoint{
+			OffsetHours:       item.DtTxt.Time.Sub(now).Hours(),
+			Temperature:       item.Main.Temp,
+			PrecipProbability: rainfallToPrecipProbability(item.Rain.ThreeH),
+		})
+	}
+	return points, nil
+}
+
+// rainfallToPrecipProbability approximates a 0-100 precipitation
+// probability from 3-hour rainfall volume in mm, since OpenWeatherMap's
+// 5-day/3-hour endpoint doesn't report probability directly.
+func rainfallToPrecipProbability(threeHourRainMM float64) float64 {
+	p := threeHourRainMM * 10
+	if p > 100 {
+		return 100
+	}
+	return p
+}
+
+// toOWMCoordinates converts a Coordinate to the type the
+// briandowns/openweathermap client expects.
+func toOWMCoordinates(coord *Coordinate) *owm.Coordinates {
+	return &owm.Coordinates{
+		Longitude: coord.Longitude,
+		Latitude:  coord.Latitude,
+	}
+}
+
+func OWMToWeather(w *owm.CurrentWeatherData, uv *owm.UV) *Weather {
+	return &Weather{
+		Temperature: w.Main.Temp,
+		Pressure:    w.Main.Pressure,
+		Humidity:    w.Main.Humidity,
+		Weather:     w.Weather[0].Main,
+		WindSpeed:   w.Wind.Speed,
+		WindDeg:     w.Wind.Deg,
+		Cloudiness:  w.Clouds.All,
+		Rainfall:    w.Rain.ThreeH / 3,
+		UV:          uv.Value,
+	}
+}
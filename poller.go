@@ -0,0 +1,185 @@
+// Copyright 2019 Yoshi Yamaguchi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// locationFails tracks, for one location, how many times in a row each
+// registered provider has failed and when it last failed. It is shared
+// by every provider's ticker goroutine for that location, hence the
+// mutex.
+type locationFails struct {
+	mu          sync.Mutex
+	count       []int
+	lastFailure []time.Time
+}
+
+// shouldSkipPrimary reports whether idx has failed ProviderErrorThreshold
+// times in a row recently enough that pollAndFallback should skip
+// straight to the fallback chain instead of probing providers[idx]
+// again. Once ProviderCooldown has passed since its last failure it
+// returns false, so pollAndFallback makes a half-open probe of the
+// primary provider instead of blacklisting it for the life of the
+// process.
+func (f *locationFails) shouldSkipPrimary(idx int) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.count[idx] < ProviderErrorThreshold {
+		return false
+	}
+	return time.Since(f.lastFailure[idx]) < ProviderCooldown
+}
+
+func (f *locationFails) reset(idx int) {
+	f.mu.Lock()
+	f.count[idx] = 0
+	f.mu.Unlock()
+}
+
+func (f *locationFails) increment(idx int) {
+	f.mu.Lock()
+	f.count[idx]++
+	f.lastFailure[idx] = time.Now()
+	f.mu.Unlock()
+}
+
+// locationPoller drives polling, for every registered provider, against
+// one configured location.
+type locationPoller struct {
+	name   string
+	cancel context.CancelFunc
+}
+
+// startLocationPoller launches one ticker goroutine per registered
+// provider for loc and returns a handle that stops them all.
+func startLocationPoller(loc LocationConfig) *locationPoller {
+	ctx, cancel := context.WithCancel(context.Background())
+	fails := &locationFails{
+		count:       make([]int, len(providers)),
+		lastFailure: make([]time.Time, len(providers)),
+	}
+
+	for i, p := range providers {
+		go func(i int, p WeatherProvider) {
+			ticker := time.NewTicker(p.MinPollInterval())
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					pollAndFallback(ctx, loc, i, fails)
+				}
+			}
+		}(i, p)
+	}
+
+	return &locationPoller{name: loc.Name, cancel: cancel}
+}
+
+// Stop cancels every ticker goroutine started for this location. It
+// does not wait for an in-flight Fetch to return.
+func (lp *locationPoller) Stop() {
+	lp.cancel()
+}
+
+// pollAndFallback fetches weather for loc from providers[idx]. Once that
+// provider has failed ProviderErrorThreshold times in a row for loc, it
+// instead walks the remaining registered providers in order and records
+// the first one that succeeds, so a single API outage doesn't blank the
+// metrics for loc's tick. It keeps probing providers[idx] again every
+// ProviderCooldown so the outage doesn't blacklist it permanently.
+func pollAndFallback(ctx context.Context, loc LocationConfig, idx int, fails *locationFails) {
+	if !fails.shouldSkipPrimary(idx) {
+		p := providers[idx]
+		w, err := p.Fetch(ctx, loc.Coordinate())
+		if err == nil {
+			fails.reset(idx)
+			if err := RecordMeasurement(p.Name(), loc.Name, w); err != nil {
+				logger.Errorf("failed to record: %v", err)
+			}
+			latestWeather.Set(p.Name(), loc.Name, w)
+			recordForecastIfSupported(ctx, p, loc)
+			return
+		}
+		fails.increment(idx)
+		logger.Errorf("failed to fetch from %s for %s: %v", p.Name(), loc.Name, err)
+	}
+
+	for i := 1; i < len(providers); i++ {
+		fb := providers[(idx+i)%len(providers)]
+		w, err := fb.Fetch(ctx, loc.Coordinate())
+		if err != nil {
+			logger.Errorf("fallback fetch from %s for %s failed: %v", fb.Name(), loc.Name, err)
+			continue
+		}
+		if err := RecordMeasurement(fb.Name(), loc.Name, w); err != nil {
+			logger.Errorf("failed to record: %v", err)
+		}
+		latestWeather.Set(fb.Name(), loc.Name, w)
+		recordForecastIfSupported(ctx, fb, loc)
+		return
+	}
+	logger.Errorf("all providers failed for %s/%s tick", providers[idx].Name(), loc.Name)
+}
+
+// recordForecastIfSupported records p's forecast for loc when p also
+// implements ForecastProvider. Forecast failures are logged but do not
+// affect the fallback chain driven by pollAndFallback, since current
+// conditions for loc were already recorded successfully.
+func recordForecastIfSupported(ctx context.Context, p WeatherProvider, loc LocationConfig) {
+	fp, ok := p.(ForecastProvider)
+	if !ok {
+		return
+	}
+
+	points, err := fp.Forecast(ctx, loc.Coordinate())
+	if err != nil {
+		logger.Errorf("failed to fetch forecast from %s for %s: %v", p.Name(), loc.Name, err)
+		return
+	}
+	for _, pt := range points {
+		if err := RecordForecastMeasurement(p.Name(), loc.Name, pt); err != nil {
+			logger.Errorf("failed to record forecast: %v", err)
+		}
+	}
+}
+
+// reloadLocations reconciles the running set of location pollers against
+// locs: pollers for locations no longer present are stopped, pollers for
+// newly added locations are started, and pollers for locations that are
+// still present (even if their other fields changed) are left running
+// untouched so in-flight requests are never dropped.
+func reloadLocations(current map[string]*locationPoller, locs []LocationConfig) map[string]*locationPoller {
+	next := make(map[string]*locationPoller, len(locs))
+	for _, loc := range locs {
+		if lp, ok := current[loc.Name]; ok {
+			next[loc.Name] = lp
+			delete(current, loc.Name)
+			continue
+		}
+		logger.Infof("starting poller for location %s", loc.Name)
+		next[loc.Name] = startLocationPoller(loc)
+	}
+	for name, lp := range current {
+		logger.Infof("stopping poller for removed location %s", name)
+		lp.Stop()
+	}
+	return next
+}
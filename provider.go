@@ -0,0 +1,75 @@
+// Copyright 2019 Yoshi Yamaguchi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// Coordinate is a geographic point susanoo polls a WeatherProvider for. It
+// is independent of any one provider's client library so new backends can
+// be added without pulling their types into the polling loop.
+type Coordinate struct {
+	Latitude  float64
+	Longitude float64
+
+	// OWMCityID is an optional OpenWeatherMap city ID for this location.
+	// OpenWeatherMapProvider queries by it instead of Latitude/Longitude
+	// when it's non-zero; every other provider ignores it.
+	OWMCityID int
+}
+
+// WeatherProvider is implemented by every weather data source susanoo
+// knows how to poll. Concrete providers are registered with
+// RegisterProvider and driven by a locationPoller per configured
+// location.
+type WeatherProvider interface {
+	// Name identifies the provider. It is used as the OpenCensus tag
+	// value that lets Stackdriver charts distinguish sources.
+	Name() string
+
+	// Fetch retrieves the current weather for coord.
+	Fetch(ctx context.Context, coord *Coordinate) (*Weather, error)
+
+	// MinPollInterval is the shortest interval the provider's backend
+	// allows susanoo to poll at.
+	MinPollInterval() time.Duration
+}
+
+// ProviderErrorThreshold is how many consecutive Fetch failures a
+// provider tolerates, for a given location, before its tick falls back
+// to the next provider in the registry.
+const ProviderErrorThreshold = 3
+
+// ProviderCooldown is how long pollAndFallback keeps skipping a
+// provider once it has crossed ProviderErrorThreshold before probing it
+// again. Without this, a provider that fails ProviderErrorThreshold
+// times in a row is skipped for the life of the process even after its
+// backend recovers.
+const ProviderCooldown = 10 * time.Minute
+
+// providers holds every registered provider in registration order. That
+// order also defines the fallback chain: when a provider's tick falls
+// back, the poller walks this slice starting right after it.
+var providers []WeatherProvider
+
+// RegisterProvider adds p to the set of providers every locationPoller
+// drives. p is polled on its own MinPollInterval ticker and, without
+// further code changes, also becomes an eligible fallback target for
+// every other registered provider.
+func RegisterProvider(p WeatherProvider) {
+	providers = append(providers, p)
+}
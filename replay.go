@@ -0,0 +1,99 @@
+// Copyright 2019 Yoshi Yamaguchi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"strings"
+	"time"
+)
+
+// runReplay implements the `susanoo replay` subcommand: it reads
+// readings back from the local SQLite store between --from and --to and
+// re-emits them through --sinks, e.g. to backfill Stackdriver after an
+// outage or to exercise the pipeline against historical data.
+func runReplay(args []string) {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	filename := fs.String("filename", "susanoo.yaml", "path to the susanoo config file")
+	from := fs.String("from", "", "RFC3339 start of the replay window (inclusive)")
+	to := fs.String("to", "", "RFC3339 end of the replay window (inclusive)")
+	sinkNames := fs.String("sinks", "opencensus", "comma-separated sinks to replay into (opencensus)")
+	fs.Parse(args)
+
+	fromTime, err := time.Parse(time.RFC3339, *from)
+	if err != nil {
+		logger.Fatalf("invalid --from: %v", err)
+	}
+	toTime, err := time.Parse(time.RFC3339, *to)
+	if err != nil {
+		logger.Fatalf("invalid --to: %v", err)
+	}
+
+	cfg, err := LoadConfig(*filename)
+	if err != nil {
+		logger.Fatalf("failed to load config %s: %v", *filename, err)
+	}
+
+	store, err := NewSQLiteSink(cfg.StorePath())
+	if err != nil {
+		logger.Fatalf("failed to open local store: %v", err)
+	}
+	defer store.Close()
+
+	readings, err := store.Replay(context.Background(), fromTime, toTime)
+	if err != nil {
+		logger.Fatalf("failed to read local store: %v", err)
+	}
+
+	targets, flush := replayTargets(*sinkNames, cfg)
+	defer flush()
+
+	logger.Infof("replaying %d readings from %s to %s into %v", len(readings), fromTime, toTime, *sinkNames)
+	for _, r := range readings {
+		w := r.Weather
+		for _, sink := range targets {
+			if err := sink.Record(context.Background(), r.Provider, r.Location, &w); err != nil {
+				logger.Errorf("sink %s failed to replay reading: %v", sink.Name(), err)
+			}
+		}
+	}
+}
+
+// replayTargets resolves a comma-separated --sinks flag into the Sinks
+// to replay readings into, initializing exporters on demand rather than
+// registering every sink up front the way main() does. The returned
+// flush func must be deferred by the caller: the opencensus sink only
+// reports on OCReportInterval, so without flushing on exit, readings
+// recorded by a short-lived replay run are lost before Stackdriver ever
+// sees them, the same reason main() defers exporter.Flush().
+func replayTargets(sinkNames string, cfg *Config) (targets []Sink, flush func()) {
+	flush = func() {}
+	for _, name := range strings.Split(sinkNames, ",") {
+		switch strings.TrimSpace(name) {
+		case "opencensus":
+			exporter := InitExporter()
+			InitOpenCensusStats(exporter)
+			InitPrometheusExporter()
+			targets = append(targets, OpenCensusSink{})
+			flush = exporter.Flush
+		case "":
+			// allow trailing commas without complaint
+		default:
+			logger.Errorf("unknown replay sink %q, ignoring", name)
+		}
+	}
+	return targets, flush
+}
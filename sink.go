@@ -0,0 +1,47 @@
+// Copyright 2019 Yoshi Yamaguchi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import "context"
+
+// Sink is implemented by every destination a recorded Weather reading
+// can be written to. RecordMeasurement fans a reading out to every sink
+// in RegisteredSinks, so a failure in one (e.g. the local store's disk
+// is full) doesn't stop the others from receiving it.
+type Sink interface {
+	Name() string
+	Record(ctx context.Context, provider, location string, w *Weather) error
+}
+
+// RegisteredSinks holds every sink RecordMeasurement fans readings out
+// to, in registration order.
+var RegisteredSinks []Sink
+
+// RegisterSink adds s to RegisteredSinks.
+func RegisterSink(s Sink) {
+	RegisteredSinks = append(RegisteredSinks, s)
+}
+
+// OpenCensusSink records readings as OpenCensus measurements. Both the
+// Stackdriver and Prometheus exporters registered by InitOpenCensusStats
+// and InitPrometheusExporter read from the same views, so a single
+// Record call here reaches both.
+type OpenCensusSink struct{}
+
+func (OpenCensusSink) Name() string { return "opencensus" }
+
+func (OpenCensusSink) Record(ctx context.Context, provider, location string, w *Weather) error {
+	return recordOpenCensusMeasurement(ctx, provider, location, w)
+}
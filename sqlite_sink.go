@@ -0,0 +1,117 @@
+// Copyright 2019 Yoshi Yamaguchi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const createReadingsTableSQL = `
+CREATE TABLE IF NOT EXISTS readings (
+	recorded_at INTEGER NOT NULL,
+	provider    TEXT NOT NULL,
+	location    TEXT NOT NULL,
+	temperature REAL NOT NULL,
+	pressure    REAL NOT NULL,
+	humidity    INTEGER NOT NULL,
+	wind_speed  REAL NOT NULL,
+	wind_deg    REAL NOT NULL,
+	cloudiness  INTEGER NOT NULL,
+	rainfall    REAL NOT NULL,
+	snowfall    REAL NOT NULL,
+	uv          REAL NOT NULL
+)`
+
+// SQLiteSink persists every recorded Weather reading to a local SQLite
+// database, so readings survive a Stackdriver outage and can be
+// replayed later with the replay subcommand.
+type SQLiteSink struct {
+	db *sql.DB
+}
+
+// NewSQLiteSink opens (creating if necessary) the SQLite database at
+// path and ensures its schema exists.
+func NewSQLiteSink(path string) (*SQLiteSink, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open local store %s: %w", path, err)
+	}
+	if _, err := db.Exec(createReadingsTableSQL); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create readings table: %w", err)
+	}
+	return &SQLiteSink{db: db}, nil
+}
+
+func (s *SQLiteSink) Name() string { return "sqlite" }
+
+// Close releases the underlying database handle.
+func (s *SQLiteSink) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLiteSink) Record(ctx context.Context, provider, location string, w *Weather) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO readings
+			(recorded_at, provider, location, temperature, pressure, humidity, wind_speed, wind_deg, cloudiness, rainfall, snowfall, uv)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		time.Now().Unix(), provider, location,
+		w.Temperature, w.Pressure, w.Humidity, w.WindSpeed, w.WindDeg, w.Cloudiness, w.Rainfall, w.Snowfall, w.UV,
+	)
+	return err
+}
+
+// Reading is one row read back from the local store by Replay.
+type Reading struct {
+	RecordedAt time.Time
+	Provider   string
+	Location   string
+	Weather    Weather
+}
+
+// Replay reads every reading recorded between from and to (inclusive),
+// ordered by recording time, so it can be re-emitted through a chosen
+// subset of sinks.
+func (s *SQLiteSink) Replay(ctx context.Context, from, to time.Time) ([]Reading, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT recorded_at, provider, location, temperature, pressure, humidity, wind_speed, wind_deg, cloudiness, rainfall, snowfall, uv
+		 FROM readings WHERE recorded_at BETWEEN ? AND ? ORDER BY recorded_at ASC`,
+		from.Unix(), to.Unix(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var readings []Reading
+	for rows.Next() {
+		var r Reading
+		var recordedAt int64
+		if err := rows.Scan(&recordedAt, &r.Provider, &r.Location,
+			&r.Weather.Temperature, &r.Weather.Pressure, &r.Weather.Humidity,
+			&r.Weather.WindSpeed, &r.Weather.WindDeg, &r.Weather.Cloudiness,
+			&r.Weather.Rainfall, &r.Weather.Snowfall, &r.Weather.UV); err != nil {
+			return nil, err
+		}
+		r.RecordedAt = time.Unix(recordedAt, 0).UTC()
+		readings = append(readings, r)
+	}
+	return readings, rows.Err()
+}